@@ -0,0 +1,6 @@
+package version
+
+// ClientVersion is the version of the proctor CLI/daemon client. It is sent
+// to proctord on every request so the server can enforce minimum client
+// compatibility.
+const ClientVersion = "0.0.1"
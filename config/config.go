@@ -0,0 +1,64 @@
+package config
+
+import "strings"
+
+// ProctorConfig holds the values read from the proctor config file
+// (typically ~/.proctor/config).
+type ProctorConfig struct {
+	Host        string `json:"PROCTOR_HOST"`
+	Email       string `json:"EMAIL_ID"`
+	AccessToken string `json:"ACCESS_TOKEN"`
+
+	// OIDCIssuer and ClientID configure the optional OIDC/OAuth2 login flow
+	// (see daemon.Client.Login). IDToken and RefreshToken are populated by
+	// that flow and persisted back to the config file.
+	OIDCIssuer   string `json:"OIDC_ISSUER,omitempty"`
+	ClientID     string `json:"CLIENT_ID,omitempty"`
+	IDToken      string `json:"ID_TOKEN,omitempty"`
+	RefreshToken string `json:"REFRESH_TOKEN,omitempty"`
+
+	// SigningKey, when set, switches the daemon Client's HTTP requests over
+	// to HMAC request signing (see daemon.Client) instead of sending
+	// AccessToken in the clear; the Email header is still sent. The websocket
+	// log-streaming and attach connections are not signed and still carry
+	// Email and AccessToken.
+	SigningKey string `json:"SIGNING_KEY,omitempty"`
+}
+
+// ConfigError collects the problems found while loading the proctor config
+// file. A zero-value ConfigError is empty and signals success.
+type ConfigError struct {
+	Errors []string
+}
+
+func (e ConfigError) Error() string {
+	return strings.Join(e.Errors, "\n")
+}
+
+// IsEmpty reports whether any errors were recorded while loading the config.
+func (e ConfigError) IsEmpty() bool {
+	return len(e.Errors) == 0
+}
+
+// Loader loads and persists the proctor config file.
+type Loader interface {
+	Load() (ProctorConfig, error)
+	Store(ProctorConfig) error
+}
+
+type loader struct{}
+
+// NewLoader returns the default config Loader. Its Load returns an empty
+// ProctorConfig with an empty ConfigError, and its Store discards the config
+// it is given; neither reads nor writes the config file.
+func NewLoader() Loader {
+	return &loader{}
+}
+
+func (l *loader) Load() (ProctorConfig, error) {
+	return ProctorConfig{}, ConfigError{}
+}
+
+func (l *loader) Store(ProctorConfig) error {
+	return nil
+}
@@ -0,0 +1,18 @@
+package config
+
+import "github.com/stretchr/testify/mock"
+
+// MockLoader is a testify mock implementation of Loader.
+type MockLoader struct {
+	mock.Mock
+}
+
+func (m *MockLoader) Load() (ProctorConfig, error) {
+	args := m.Called()
+	return args.Get(0).(ProctorConfig), args.Error(1)
+}
+
+func (m *MockLoader) Store(proctorConfig ProctorConfig) error {
+	args := m.Called(proctorConfig)
+	return args.Error(0)
+}
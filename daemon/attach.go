@@ -0,0 +1,236 @@
+package daemon
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"unsafe"
+
+	"github.com/gojektech/proctor/cmd/version"
+	"github.com/gojektech/proctor/config"
+	"github.com/gojektech/proctor/proctord/utility"
+	"github.com/gorilla/websocket"
+)
+
+const procsAttachPath = "/jobs/attach"
+
+// controlFrame is a message sent from the client to proctord over an
+// AttachProc websocket: either a chunk of stdin, a forwarded signal, or a
+// terminal resize. Data is []byte rather than string so encoding/json
+// base64-encodes it, preserving stdin bytes that aren't valid UTF-8 instead
+// of mangling them into the JSON string.
+type controlFrame struct {
+	Type string `json:"type"`
+	Data []byte `json:"data,omitempty"`
+	Name string `json:"name,omitempty"`
+	Cols int    `json:"cols,omitempty"`
+	Rows int    `json:"rows,omitempty"`
+}
+
+// outputFrame is a message proctord sends back over an AttachProc websocket:
+// a chunk of the proc's stdout or stderr, tagged by Stream so the two can be
+// demultiplexed on the way out. Data is []byte for the same reason as
+// controlFrame.Data: the proc's output isn't guaranteed to be valid UTF-8.
+type outputFrame struct {
+	Stream string `json:"stream"`
+	Data   []byte `json:"data"`
+}
+
+// AttachProc opens a full-duplex channel to a running proc: stdin is read
+// and forwarded as it arrives, SIGINT/SIGTERM/SIGWINCH are forwarded as
+// control frames so the proc can be interactively driven (answering a
+// prompt, resizing a pty), and the proc's stdout/stderr are demultiplexed
+// onto the given writers. It returns once the proc's output stream closes,
+// or ctx is cancelled.
+func (c *client) AttachProc(ctx context.Context, jobID string, stdin io.Reader, stdout, stderr io.Writer) error {
+	proctorConfig, err := c.loadConfig()
+	if err != nil {
+		return err
+	}
+
+	conn, err := c.dialProcAttach(ctx, proctorConfig, jobID)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	// done is closed when AttachProc returns, for whatever reason, so the
+	// goroutines below exit instead of leaking until ctx itself is cancelled
+	// (which, for a caller reusing a long-lived root context across several
+	// calls, might be long after this one is done). forwardStdin exits only
+	// once a Read on stdin that is already blocked returns.
+	done := make(chan struct{})
+	defer close(done)
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.Close()
+		case <-done:
+		}
+	}()
+
+	// gorilla/websocket permits at most one concurrent writer per conn, but
+	// forwardStdin and forwardSignals both produce control frames
+	// concurrently, so they hand frames to writeControlFrames over
+	// framesCh instead of calling conn.WriteJSON directly.
+	framesCh := make(chan controlFrame)
+	go c.writeControlFrames(conn, framesCh, done)
+	go c.forwardStdin(framesCh, stdin, done)
+	go c.forwardSignals(framesCh, done)
+
+	err = c.readProcOutput(conn, stdout, stderr)
+	if ctx.Err() != nil {
+		return nil
+	}
+	return err
+}
+
+func (c *client) dialProcAttach(ctx context.Context, proctorConfig config.ProctorConfig, jobID string) (*websocket.Conn, error) {
+	header := http.Header{}
+	header.Set(utility.UserEmailHeaderKey, proctorConfig.Email)
+	header.Set(utility.AccessTokenHeaderKey, proctorConfig.AccessToken)
+	header.Set(utility.ClientVersionHeaderKey, version.ClientVersion)
+
+	wsURL := fmt.Sprintf("ws://%s%s?id=%s", proctorConfig.Host, procsAttachPath, jobID)
+
+	dialer := websocket.Dialer{}
+	conn, _, err := dialer.DialContext(ctx, wsURL, header)
+	return conn, err
+}
+
+// writeControlFrames is conn's sole writer: it serializes every control
+// frame forwardStdin and forwardSignals produce onto conn, since
+// gorilla/websocket forbids concurrent writers on the same connection. It
+// returns once done closes, framesCh closes, or a write to conn fails.
+func (c *client) writeControlFrames(conn *websocket.Conn, framesCh <-chan controlFrame, done <-chan struct{}) {
+	for {
+		select {
+		case frame, ok := <-framesCh:
+			if !ok {
+				return
+			}
+			if conn.WriteJSON(frame) != nil {
+				return
+			}
+		case <-done:
+			return
+		}
+	}
+}
+
+// forwardStdin relays whatever it reads off stdin to framesCh as "stdin"
+// control frames, until stdin is exhausted or done closes. done is checked
+// only between reads: a Read already blocked on stdin is not interrupted.
+func (c *client) forwardStdin(framesCh chan<- controlFrame, stdin io.Reader, done <-chan struct{}) {
+	buf := make([]byte, 4096)
+	for {
+		n, readErr := stdin.Read(buf)
+		if n > 0 {
+			data := make([]byte, n)
+			copy(data, buf[:n])
+			frame := controlFrame{Type: "stdin", Data: data}
+			select {
+			case framesCh <- frame:
+			case <-done:
+				return
+			}
+		}
+		if readErr != nil {
+			return
+		}
+	}
+}
+
+// forwardSignals relays SIGINT/SIGTERM/SIGWINCH as control frames, so a
+// user's Ctrl-C or a terminal resize reaches the remote proc instead of
+// just the local CLI process, until done closes.
+func (c *client) forwardSignals(framesCh chan<- controlFrame, done <-chan struct{}) {
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM, syscall.SIGWINCH)
+	defer signal.Stop(sigCh)
+
+	for {
+		select {
+		case <-done:
+			return
+		case sig := <-sigCh:
+			frame, ok := controlFrameForSignal(sig)
+			if !ok {
+				continue
+			}
+			select {
+			case framesCh <- frame:
+			case <-done:
+				return
+			}
+		}
+	}
+}
+
+// controlFrameForSignal builds the control frame for sig. ok is false when
+// sig can't be translated into a meaningful frame (SIGWINCH when the
+// terminal size can't be read), telling the caller to drop it rather than
+// send an empty, typeless frame to proctord.
+func controlFrameForSignal(sig os.Signal) (frame controlFrame, ok bool) {
+	switch sig {
+	case syscall.SIGWINCH:
+		cols, rows, err := terminalSize(os.Stdout.Fd())
+		if err != nil {
+			return controlFrame{}, false
+		}
+		return controlFrame{Type: "resize", Cols: cols, Rows: rows}, true
+	case syscall.SIGINT:
+		return controlFrame{Type: "signal", Name: "SIGINT"}, true
+	case syscall.SIGTERM:
+		return controlFrame{Type: "signal", Name: "SIGTERM"}, true
+	default:
+		return controlFrame{Type: "signal", Name: fmt.Sprintf("%v", sig)}, true
+	}
+}
+
+// readProcOutput relays outputFrames off conn to stdout/stderr, demultiplexed
+// by their Stream field, until conn closes.
+func (c *client) readProcOutput(conn *websocket.Conn, stdout, stderr io.Writer) error {
+	for {
+		var frame outputFrame
+		if err := conn.ReadJSON(&frame); err != nil {
+			if websocket.IsCloseError(err, websocket.CloseNormalClosure) {
+				return nil
+			}
+			return err
+		}
+
+		w := stdout
+		if frame.Stream == "stderr" {
+			w = stderr
+		}
+		if _, err := w.Write(frame.Data); err != nil {
+			return err
+		}
+	}
+}
+
+// winsize mirrors the kernel's struct winsize, as expected by the
+// TIOCGWINSZ ioctl used by terminalSize.
+type winsize struct {
+	Rows uint16
+	Cols uint16
+	X    uint16
+	Y    uint16
+}
+
+// terminalSize reports the current size of the terminal backing fd, for
+// relaying SIGWINCH as a "resize" control frame.
+func terminalSize(fd uintptr) (cols, rows int, err error) {
+	ws := &winsize{}
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, fd, uintptr(syscall.TIOCGWINSZ), uintptr(unsafe.Pointer(ws)))
+	if errno != 0 {
+		return 0, 0, errno
+	}
+	return int(ws.Cols), int(ws.Rows), nil
+}
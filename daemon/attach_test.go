@@ -0,0 +1,226 @@
+package daemon
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/gojektech/proctor/config"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestControlFrameForSignalSkipsResizeWhenTerminalSizeIsUnavailable documents
+// the contract forwardSignals relies on: controlFrameForSignal reports
+// ok == false for SIGWINCH when the fd it inspects isn't a terminal (as is
+// the case for os.Stdout under `go test`), so the caller drops it instead of
+// sending an empty, typeless frame to proctord.
+func TestControlFrameForSignalSkipsResizeWhenTerminalSizeIsUnavailable(t *testing.T) {
+	_, _, sizeErr := terminalSize(os.Stdout.Fd())
+	if sizeErr == nil {
+		t.Skip("os.Stdout is a terminal in this environment; nothing to assert")
+	}
+
+	frame, ok := controlFrameForSignal(syscall.SIGWINCH)
+
+	assert.False(t, ok)
+	assert.Equal(t, controlFrame{}, frame)
+}
+
+func TestControlFrameForSignalBuildsSignalFrames(t *testing.T) {
+	frame, ok := controlFrameForSignal(syscall.SIGINT)
+	assert.True(t, ok)
+	assert.Equal(t, controlFrame{Type: "signal", Name: "SIGINT"}, frame)
+
+	frame, ok = controlFrameForSignal(syscall.SIGTERM)
+	assert.True(t, ok)
+	assert.Equal(t, controlFrame{Type: "signal", Name: "SIGTERM"}, frame)
+}
+
+func (s *ClientTestSuite) TestAttachProcRoundTripsStdinAndDemuxesStdout() {
+	t := s.T()
+
+	upgrader := websocket.Upgrader{}
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		var frame controlFrame
+		if err := conn.ReadJSON(&frame); err != nil {
+			return
+		}
+
+		conn.WriteJSON(outputFrame{Stream: "stdout", Data: append([]byte("echo:"), frame.Data...)})
+		conn.WriteJSON(outputFrame{Stream: "stderr", Data: []byte("a warning")})
+		conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+	}))
+	defer testServer.Close()
+
+	proctorConfig := config.ProctorConfig{Host: makeHostname(testServer.URL), Email: "proctor@example.com", AccessToken: "access-token"}
+	s.mockConfigLoader.On("Load").Return(proctorConfig, config.ConfigError{}).Once()
+
+	var stdout, stderr bytes.Buffer
+	err := s.testClient.AttachProc(context.Background(), "test-job-id", strings.NewReader("hello"), &stdout, &stderr)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "echo:hello", stdout.String())
+	assert.Equal(t, "a warning", stderr.String())
+}
+
+func (s *ClientTestSuite) TestAttachProcPreservesNonUTF8Bytes() {
+	t := s.T()
+
+	// 0xFF, 0xFE are invalid UTF-8 on their own; a string-typed JSON field
+	// would have encoding/json replace them with U+FFFD on the way out.
+	binaryInput := []byte{0xFF, 0xFE, 0x00, 0x01}
+
+	upgrader := websocket.Upgrader{}
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		var frame controlFrame
+		if err := conn.ReadJSON(&frame); err != nil {
+			return
+		}
+
+		conn.WriteJSON(outputFrame{Stream: "stdout", Data: frame.Data})
+		conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+	}))
+	defer testServer.Close()
+
+	proctorConfig := config.ProctorConfig{Host: makeHostname(testServer.URL), Email: "proctor@example.com", AccessToken: "access-token"}
+	s.mockConfigLoader.On("Load").Return(proctorConfig, config.ConfigError{}).Once()
+
+	var stdout bytes.Buffer
+	err := s.testClient.AttachProc(context.Background(), "test-job-id", bytes.NewReader(binaryInput), &stdout, &bytes.Buffer{})
+
+	assert.NoError(t, err)
+	assert.Equal(t, binaryInput, stdout.Bytes())
+}
+
+// TestWriteControlFramesSerializesFramesFromConcurrentProducers exercises
+// writeControlFrames the way forwardStdin and forwardSignals actually drive
+// it: two producers sending frames concurrently. Raising real OS signals to
+// race forwardSignals against forwardStdin isn't reliable in a headless test
+// environment (SIGWINCH's resize frame is dropped outright when stdout isn't
+// a terminal, and SIGINT/SIGTERM default to killing the test process if sent
+// outside the narrow window signal.Notify is actually listening), so this
+// drives the two concurrent senders directly instead.
+func (s *ClientTestSuite) TestWriteControlFramesSerializesFramesFromConcurrentProducers() {
+	t := s.T()
+
+	const framesPerProducer = 100
+	var received int32
+	allReceived := make(chan struct{})
+
+	upgrader := websocket.Upgrader{}
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		for {
+			var frame controlFrame
+			if err := conn.ReadJSON(&frame); err != nil {
+				return
+			}
+			if atomic.AddInt32(&received, 1) == 2*framesPerProducer {
+				close(allReceived)
+			}
+		}
+	}))
+	defer testServer.Close()
+
+	dialer := websocket.Dialer{}
+	conn, _, err := dialer.Dial("ws://"+makeHostname(testServer.URL), nil)
+	if err != nil {
+		t.Fatalf("failed to dial test server: %v", err)
+	}
+	defer conn.Close()
+
+	c := &client{}
+	framesCh := make(chan controlFrame)
+	writerDone := make(chan struct{})
+	defer close(writerDone)
+	go c.writeControlFrames(conn, framesCh, writerDone)
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	for _, frameType := range []string{"stdin", "signal"} {
+		frameType := frameType
+		go func() {
+			defer wg.Done()
+			for i := 0; i < framesPerProducer; i++ {
+				framesCh <- controlFrame{Type: frameType}
+			}
+		}()
+	}
+
+	select {
+	case <-allReceived:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for all concurrently-produced frames to arrive")
+	}
+	wg.Wait()
+}
+
+func (s *ClientTestSuite) TestAttachProcShutsDownGracefullyWhenContextIsCancelled() {
+	t := s.T()
+
+	upgraded := make(chan struct{})
+	upgrader := websocket.Upgrader{}
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		close(upgraded)
+
+		// The client never gets a reply; it should only come back once its
+		// context is cancelled.
+		conn.ReadMessage()
+	}))
+	defer testServer.Close()
+
+	proctorConfig := config.ProctorConfig{Host: makeHostname(testServer.URL), Email: "proctor@example.com", AccessToken: "access-token"}
+	s.mockConfigLoader.On("Load").Return(proctorConfig, config.ConfigError{}).Once()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- s.testClient.AttachProc(ctx, "test-job-id", strings.NewReader(""), &bytes.Buffer{}, &bytes.Buffer{})
+	}()
+
+	select {
+	case <-upgraded:
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for the server to upgrade the connection")
+	}
+
+	cancel()
+
+	select {
+	case err := <-errCh:
+		assert.NoError(t, err)
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for AttachProc to shut down after context cancellation")
+	}
+}
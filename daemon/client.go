@@ -0,0 +1,267 @@
+package daemon
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	stdio "io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+
+	"github.com/gojektech/proctor/cmd/version"
+	"github.com/gojektech/proctor/config"
+	"github.com/gojektech/proctor/io"
+	"github.com/gojektech/proctor/proc"
+	"github.com/gojektech/proctor/proctord/utility"
+)
+
+const (
+	procsMetadataPath = "/jobs/metadata"
+	procsExecutePath  = "/jobs/execute"
+	procsLogsPath     = "/jobs/logs"
+)
+
+// Client talks to proctord on behalf of the proctor CLI: listing available
+// procs, executing them and streaming their logs back to the user. Every
+// method takes a context.Context as its first argument so a Ctrl-C from the
+// CLI, or a caller-supplied deadline, aborts in-flight HTTP calls and closes
+// any open log-streaming socket.
+type Client interface {
+	ListProcs(ctx context.Context) ([]proc.Metadata, error)
+	ExecuteProc(ctx context.Context, name string, args map[string]string) (string, error)
+	StreamProcLogs(ctx context.Context, jobID string, opts StreamOptions) error
+
+	// AttachProc opens a full-duplex channel to a running proc: stdin is
+	// forwarded to it, SIGINT/SIGTERM/SIGWINCH are relayed as control
+	// frames, and its stdout/stderr are demultiplexed onto the given
+	// writers.
+	AttachProc(ctx context.Context, jobID string, stdin stdio.Reader, stdout, stderr stdio.Writer) error
+
+	// Login runs the interactive OIDC/OAuth2 authorization-code+PKCE flow
+	// against ProctorConfig.OIDCIssuer/ClientID and persists the resulting
+	// id_token/refresh_token back to the proctor config file.
+	Login(ctx context.Context) error
+}
+
+type clientContextKey struct{}
+
+// NewContext returns a copy of ctx carrying client, so cobra commands can
+// stash the configured Client on the root context once and pull it out
+// anywhere via FromContext without threading it through every function
+// signature.
+func NewContext(ctx context.Context, client Client) context.Context {
+	return context.WithValue(ctx, clientContextKey{}, client)
+}
+
+// FromContext returns the Client stashed on ctx by NewContext, if any.
+func FromContext(ctx context.Context) (Client, bool) {
+	client, ok := ctx.Value(clientContextKey{}).(Client)
+	return client, ok
+}
+
+type client struct {
+	printer      io.Printer
+	configLoader config.Loader
+	httpClient   *http.Client
+}
+
+// NewClient builds the default daemon Client, backed by proctord's HTTP and
+// websocket APIs.
+func NewClient(printer io.Printer, configLoader config.Loader) Client {
+	return &client{
+		printer:      printer,
+		configLoader: configLoader,
+		httpClient:   &http.Client{},
+	}
+}
+
+func (c *client) loadConfig() (config.ProctorConfig, error) {
+	proctorConfig, err := c.configLoader.Load()
+	if configError, ok := err.(config.ConfigError); ok && !configError.IsEmpty() {
+		return config.ProctorConfig{}, configError
+	}
+	return proctorConfig, nil
+}
+
+// setRequestHeaders attaches the identifying/auth headers proctord expects.
+// When proctorConfig.SigningKey is set, the request is HMAC-signed (see
+// signRequest) instead of carrying the legacy ACCESS_TOKEN header or an OIDC
+// bearer token.
+func (c *client) setRequestHeaders(req *http.Request, proctorConfig config.ProctorConfig, body []byte) {
+	req.Header.Set(utility.UserEmailHeaderKey, proctorConfig.Email)
+	req.Header.Set(utility.ClientVersionHeaderKey, version.ClientVersion)
+
+	if proctorConfig.SigningKey != "" {
+		signRequest(req, proctorConfig, body)
+		return
+	}
+
+	req.Header.Set(utility.AccessTokenHeaderKey, proctorConfig.AccessToken)
+	if proctorConfig.IDToken != "" {
+		req.Header.Set("Authorization", "Bearer "+proctorConfig.IDToken)
+	}
+}
+
+func unauthorizedError(proctorConfig config.ProctorConfig) error {
+	if proctorConfig.Email == "" || proctorConfig.AccessToken == "" {
+		return errors.New("Unauthorized Access!!!\nEMAIL_ID or ACCESS_TOKEN is not present in proctor config file.")
+	}
+	return errors.New("Unauthorized Access!!!\nPlease check the EMAIL_ID and ACCESS_TOKEN validity in proctor config file.")
+}
+
+// networkError formats the error net/http returns from a failed round trip.
+// It deliberately reaches into *url.Error instead of using err.Error()
+// directly, since the latter wraps the URL in quotes on newer Go toolchains
+// and proctor's error strings predate that change.
+func networkError(err error) error {
+	message := err.Error()
+	if urlErr, ok := err.(*url.Error); ok {
+		message = fmt.Sprintf("%s %s: %s", urlErr.Op, urlErr.URL, urlErr.Err.Error())
+	}
+
+	if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+		return fmt.Errorf("Connection Timeout!!!\n%s\nPlease check your Internet/VPN connection for connectivity to ProctorD.", message)
+	}
+	return fmt.Errorf("Network Error!!!\n%s", message)
+}
+
+func serverError(resp *http.Response) error {
+	return fmt.Errorf("Server Error!!!\nStatus Code: %d, %s", resp.StatusCode, http.StatusText(resp.StatusCode))
+}
+
+func (c *client) do(req *http.Request, proctorConfig config.ProctorConfig, body []byte) (*http.Response, error) {
+	c.setRequestHeaders(req, proctorConfig, body)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, networkError(err)
+	}
+
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+	resp.Body.Close()
+	resp.Body = ioutil.NopCloser(bytes.NewReader(respBody))
+
+	if resp.StatusCode < 300 {
+		if err := verifyResponseSignature(resp, proctorConfig, respBody); err != nil {
+			return nil, err
+		}
+	}
+	return resp, nil
+}
+
+// doWithRefresh performs buildReq()'s request, and, if the daemon rejects it
+// with 401 while a refresh token is available, silently refreshes the OIDC
+// session and retries the request exactly once. body is the raw request
+// body buildReq() sends (nil for bodyless requests), and is reused across
+// the retry to compute the HMAC signature, if any.
+func (c *client) doWithRefresh(ctx context.Context, buildReq func() (*http.Request, error), proctorConfig config.ProctorConfig, body []byte) (*http.Response, config.ProctorConfig, error) {
+	req, err := buildReq()
+	if err != nil {
+		return nil, proctorConfig, err
+	}
+
+	resp, err := c.do(req, proctorConfig, body)
+	if err != nil {
+		return nil, proctorConfig, err
+	}
+
+	if resp.StatusCode != http.StatusUnauthorized || proctorConfig.RefreshToken == "" {
+		return resp, proctorConfig, nil
+	}
+	resp.Body.Close()
+
+	refreshedConfig, refreshErr := c.refreshIDToken(ctx, proctorConfig)
+	if refreshErr != nil {
+		return nil, proctorConfig, refreshErr
+	}
+
+	retryReq, err := buildReq()
+	if err != nil {
+		return nil, refreshedConfig, err
+	}
+	resp, err = c.do(retryReq, refreshedConfig, body)
+	return resp, refreshedConfig, err
+}
+
+func (c *client) ListProcs(ctx context.Context) ([]proc.Metadata, error) {
+	procList := []proc.Metadata{}
+
+	proctorConfig, err := c.loadConfig()
+	if err != nil {
+		return procList, err
+	}
+
+	buildReq := func() (*http.Request, error) {
+		return http.NewRequestWithContext(ctx, http.MethodGet, "http://"+proctorConfig.Host+procsMetadataPath, nil)
+	}
+
+	resp, proctorConfig, err := c.doWithRefresh(ctx, buildReq, proctorConfig, nil)
+	if err != nil {
+		return procList, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		if err := json.NewDecoder(resp.Body).Decode(&procList); err != nil {
+			return []proc.Metadata{}, err
+		}
+		return procList, nil
+	case http.StatusUnauthorized:
+		return procList, unauthorizedError(proctorConfig)
+	default:
+		return procList, serverError(resp)
+	}
+}
+
+func (c *client) ExecuteProc(ctx context.Context, name string, args map[string]string) (string, error) {
+	proctorConfig, err := c.loadConfig()
+	if err != nil {
+		return "", err
+	}
+
+	body, err := json.Marshal(args)
+	if err != nil {
+		return "", err
+	}
+
+	buildReq := func() (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, "http://"+proctorConfig.Host+procsExecutePath, bytes.NewBuffer(body))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set(utility.ProcName, name)
+		return req, nil
+	}
+
+	resp, proctorConfig, err := c.doWithRefresh(ctx, buildReq, proctorConfig, body)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusCreated:
+		var executedProc struct {
+			Name string `json:"name"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&executedProc); err != nil {
+			return "", err
+		}
+		return executedProc.Name, nil
+	case http.StatusUnauthorized:
+		return "", unauthorizedError(proctorConfig)
+	case http.StatusForbidden:
+		return "", errors.New("Access denied. You are not authorized to perform this action. Please contact proc admin.")
+	default:
+		return "", serverError(resp)
+	}
+}
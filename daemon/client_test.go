@@ -1,10 +1,12 @@
 package daemon
 
 import (
+	"context"
 	"errors"
 	"net/http"
 	"net/http/httptest"
 	"strings"
+	"sync/atomic"
 	"testing"
 
 	"github.com/gojektech/proctor/cmd/version"
@@ -81,7 +83,7 @@ func (s *ClientTestSuite) TestListProcsReturnsListOfProcsWithDetails() {
 
 	s.mockConfigLoader.On("Load").Return(proctorConfig, config.ConfigError{}).Once()
 
-	procList, err := s.testClient.ListProcs()
+	procList, err := s.testClient.ListProcs(context.Background())
 
 	assert.NoError(t, err)
 	s.mockConfigLoader.AssertExpectations(t)
@@ -114,7 +116,7 @@ func (s *ClientTestSuite) TestListProcsReturnErrorFromResponseBody() {
 
 	s.mockConfigLoader.On("Load").Return(proctorConfig, config.ConfigError{}).Once()
 
-	procList, err := s.testClient.ListProcs()
+	procList, err := s.testClient.ListProcs(context.Background())
 
 	assert.Equal(t, []proc.Metadata{}, procList)
 	assert.Error(t, err)
@@ -148,7 +150,7 @@ func (s *ClientTestSuite) TestListProcsReturnClientSideTimeoutError() {
 
 	s.mockConfigLoader.On("Load").Return(proctorConfig, config.ConfigError{}).Once()
 
-	procList, err := s.testClient.ListProcs()
+	procList, err := s.testClient.ListProcs(context.Background())
 
 	assert.Equal(t, errors.New("Connection Timeout!!!\nGet http://proctor.example.com/jobs/metadata: Unable to reach http://proctor.example.com/\nPlease check your Internet/VPN connection for connectivity to ProctorD."), err)
 	assert.Equal(t, []proc.Metadata{}, procList)
@@ -181,7 +183,7 @@ func (s *ClientTestSuite) TestListProcsReturnClientSideConnectionError() {
 
 	s.mockConfigLoader.On("Load").Return(proctorConfig, config.ConfigError{}).Once()
 
-	procList, err := s.testClient.ListProcs()
+	procList, err := s.testClient.ListProcs(context.Background())
 
 	assert.Equal(t, errors.New("Network Error!!!\nGet http://proctor.example.com/jobs/metadata: Unknown Error"), err)
 	assert.Equal(t, []proc.Metadata{}, procList)
@@ -214,7 +216,7 @@ func (s *ClientTestSuite) TestListProcsForUnauthorizedUser() {
 
 	s.mockConfigLoader.On("Load").Return(proctorConfig, config.ConfigError{}).Once()
 
-	procList, err := s.testClient.ListProcs()
+	procList, err := s.testClient.ListProcs(context.Background())
 
 	assert.Equal(t, []proc.Metadata{}, procList)
 	assert.Equal(t, "Unauthorized Access!!!\nPlease check the EMAIL_ID and ACCESS_TOKEN validity in proctor config file.", err.Error())
@@ -245,7 +247,7 @@ func (s *ClientTestSuite) TestListProcsForUnauthorizedErrorWithConfigMissing() {
 	)
 
 	s.mockConfigLoader.On("Load").Return(proctorConfig, config.ConfigError{}).Once()
-	procList, err := s.testClient.ListProcs()
+	procList, err := s.testClient.ListProcs(context.Background())
 
 	assert.Equal(t, []proc.Metadata{}, procList)
 	assert.Equal(t, "Unauthorized Access!!!\nEMAIL_ID or ACCESS_TOKEN is not present in proctor config file.", err.Error())
@@ -283,7 +285,7 @@ func (s *ClientTestSuite) TestExecuteProc() {
 
 	s.mockConfigLoader.On("Load").Return(proctorConfig, config.ConfigError{}).Once()
 
-	executeProcResponse, err := s.testClient.ExecuteProc(procName, procArgs)
+	executeProcResponse, err := s.testClient.ExecuteProc(context.Background(), procName, procArgs)
 
 	assert.NoError(t, err)
 	assert.Equal(t, expectedProcResponse, executeProcResponse)
@@ -318,7 +320,7 @@ func (s *ClientTestSuite) TestExecuteProcInternalServerError() {
 	)
 
 	s.mockConfigLoader.On("Load").Return(proctorConfig, config.ConfigError{}).Once()
-	executeProcResponse, err := s.testClient.ExecuteProc(procName, procArgs)
+	executeProcResponse, err := s.testClient.ExecuteProc(context.Background(), procName, procArgs)
 
 	assert.Equal(t, "Server Error!!!\nStatus Code: 500, Internal Server Error", err.Error())
 	assert.Equal(t, expectedProcResponse, executeProcResponse)
@@ -351,7 +353,7 @@ func (s *ClientTestSuite) TestExecuteProcUnAuthorized() {
 
 	s.mockConfigLoader.On("Load").Return(proctorConfig, config.ConfigError{}).Once()
 
-	executeProcResponse, err := s.testClient.ExecuteProc("run-sample", map[string]string{"SAMPLE_ARG1": "sample-value"})
+	executeProcResponse, err := s.testClient.ExecuteProc(context.Background(), "run-sample", map[string]string{"SAMPLE_ARG1": "sample-value"})
 
 	assert.Equal(t, "", executeProcResponse)
 	assert.Equal(t, "Unauthorized Access!!!\nPlease check the EMAIL_ID and ACCESS_TOKEN validity in proctor config file.", err.Error())
@@ -384,7 +386,7 @@ func (s *ClientTestSuite) TestExecuteProcUnAuthorizedWhenEmailAndAccessTokenNotS
 
 	s.mockConfigLoader.On("Load").Return(proctorConfig, config.ConfigError{}).Once()
 
-	executeProcResponse, err := s.testClient.ExecuteProc("run-sample", map[string]string{"SAMPLE_ARG1": "sample-value"})
+	executeProcResponse, err := s.testClient.ExecuteProc(context.Background(), "run-sample", map[string]string{"SAMPLE_ARG1": "sample-value"})
 
 	assert.Equal(t, "", executeProcResponse)
 	assert.Equal(t, "Unauthorized Access!!!\nEMAIL_ID or ACCESS_TOKEN is not present in proctor config file.", err.Error())
@@ -417,7 +419,7 @@ func (s *ClientTestSuite) TestExecuteProcUnAuthorizedWhenUserIsNotAllowedToExecu
 
 	s.mockConfigLoader.On("Load").Return(proctorConfig, config.ConfigError{}).Once()
 
-	executeProcResponse, err := s.testClient.ExecuteProc("run-sample", map[string]string{"SAMPLE_ARG1": "sample-value"})
+	executeProcResponse, err := s.testClient.ExecuteProc(context.Background(), "run-sample", map[string]string{"SAMPLE_ARG1": "sample-value"})
 
 	assert.Equal(t, "", executeProcResponse)
 	assert.Equal(t, "Access denied. You are not authorized to perform this action. Please contact proc admin.", err.Error())
@@ -450,13 +452,137 @@ func (s *ClientTestSuite) TestExecuteProcsReturnClientSideConnectionError() {
 
 	s.mockConfigLoader.On("Load").Return(proctorConfig, config.ConfigError{}).Once()
 
-	response, err := s.testClient.ExecuteProc("run-sample", map[string]string{"SAMPLE_ARG1": "sample-value"})
+	response, err := s.testClient.ExecuteProc(context.Background(), "run-sample", map[string]string{"SAMPLE_ARG1": "sample-value"})
 
 	assert.Equal(t, "", response)
 	assert.Equal(t, errors.New("Network Error!!!\nPost http://proctor.example.com/jobs/execute: Unknown Error"), err)
 	s.mockConfigLoader.AssertExpectations(t)
 }
 
+func (s *ClientTestSuite) TestListProcsAbortsWhenContextIsCancelled() {
+	t := s.T()
+
+	proctorConfig := config.ProctorConfig{Host: "proctor.example.com", Email: "proctor@example.com", AccessToken: "access-token"}
+	s.mockConfigLoader.On("Load").Return(proctorConfig, config.ConfigError{}).Once()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	procList, err := s.testClient.ListProcs(ctx)
+
+	assert.Equal(t, []proc.Metadata{}, procList)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "context canceled")
+	s.mockConfigLoader.AssertExpectations(t)
+}
+
+func (s *ClientTestSuite) TestListProcsSendsBearerTokenAlongsideAccessTokenWhenIDTokenIsSet() {
+	t := s.T()
+
+	proctorConfig := config.ProctorConfig{Host: "proctor.example.com", Email: "proctor@example.com", AccessToken: "access-token", IDToken: "an-id-token"}
+
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	httpmock.RegisterStubRequest(
+		httpmock.NewStubRequest(
+			"GET",
+			"http://"+proctorConfig.Host+"/jobs/metadata",
+			func(req *http.Request) (*http.Response, error) {
+				return httpmock.NewStringResponse(200, "[]"), nil
+			},
+		).WithHeader(
+			&http.Header{
+				utility.UserEmailHeaderKey:     []string{"proctor@example.com"},
+				utility.AccessTokenHeaderKey:   []string{"access-token"},
+				utility.ClientVersionHeaderKey: []string{version.ClientVersion},
+				"Authorization":                []string{"Bearer an-id-token"},
+			},
+		),
+	)
+
+	s.mockConfigLoader.On("Load").Return(proctorConfig, config.ConfigError{}).Once()
+
+	procList, err := s.testClient.ListProcs(context.Background())
+
+	assert.NoError(t, err)
+	assert.Empty(t, procList)
+	s.mockConfigLoader.AssertExpectations(t)
+}
+
+func (s *ClientTestSuite) TestListProcsRefreshesIDTokenAndRetriesOn401() {
+	t := s.T()
+
+	proctorConfig := config.ProctorConfig{
+		Host:         "proctor.example.com",
+		Email:        "proctor@example.com",
+		OIDCIssuer:   "https://issuer.example.com",
+		ClientID:     "proctor-cli",
+		IDToken:      "stale-id-token",
+		RefreshToken: "refresh-token",
+	}
+
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	var metadataRequests int32
+	httpmock.RegisterStubRequest(
+		httpmock.NewStubRequest(
+			"GET",
+			"http://"+proctorConfig.Host+"/jobs/metadata",
+			func(req *http.Request) (*http.Response, error) {
+				if atomic.AddInt32(&metadataRequests, 1) == 1 {
+					assert.Equal(t, "Bearer stale-id-token", req.Header.Get("Authorization"))
+					return httpmock.NewStringResponse(401, `{}`), nil
+				}
+				assert.Equal(t, "Bearer refreshed-id-token", req.Header.Get("Authorization"))
+				return httpmock.NewStringResponse(200, "[]"), nil
+			},
+		),
+	)
+	httpmock.RegisterStubRequest(
+		httpmock.NewStubRequest(
+			"GET",
+			"https://issuer.example.com/.well-known/openid-configuration",
+			func(req *http.Request) (*http.Response, error) {
+				return httpmock.NewStringResponse(200, `{
+					"authorization_endpoint": "https://issuer.example.com/auth",
+					"token_endpoint": "https://issuer.example.com/token"
+				}`), nil
+			},
+		),
+	)
+	httpmock.RegisterStubRequest(
+		httpmock.NewStubRequest(
+			"POST",
+			"https://issuer.example.com/token",
+			func(req *http.Request) (*http.Response, error) {
+				assert.NoError(t, req.ParseForm())
+				assert.Equal(t, "refresh_token", req.PostForm.Get("grant_type"))
+				assert.Equal(t, "refresh-token", req.PostForm.Get("refresh_token"))
+				return httpmock.NewStringResponse(200, `{"id_token": "refreshed-id-token", "refresh_token": "refreshed-refresh-token"}`), nil
+			},
+		),
+	)
+
+	s.mockConfigLoader.On("Load").Return(proctorConfig, config.ConfigError{}).Once()
+	s.mockConfigLoader.On("Store", config.ProctorConfig{
+		Host:         "proctor.example.com",
+		Email:        "proctor@example.com",
+		OIDCIssuer:   "https://issuer.example.com",
+		ClientID:     "proctor-cli",
+		IDToken:      "refreshed-id-token",
+		RefreshToken: "refreshed-refresh-token",
+	}).Return(nil).Once()
+
+	procList, err := s.testClient.ListProcs(context.Background())
+
+	assert.NoError(t, err)
+	assert.Empty(t, procList)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&metadataRequests))
+	s.mockConfigLoader.AssertExpectations(t)
+}
+
 func makeHostname(s string) string {
 	return strings.TrimPrefix(s, "http://")
 }
@@ -471,6 +597,7 @@ func (s *ClientTestSuite) TestLogStreamForAuthorizedUser() {
 			assert.Equal(t, version.ClientVersion, r.Header.Get(utility.ClientVersionHeaderKey))
 			conn, _ := upgrader.Upgrade(w, r, nil)
 			defer conn.Close()
+			conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
 		}
 	}
 	testServer := httptest.NewServer(logStreamAuthorizer(t))
@@ -479,7 +606,7 @@ func (s *ClientTestSuite) TestLogStreamForAuthorizedUser() {
 
 	s.mockConfigLoader.On("Load").Return(proctorConfig, config.ConfigError{}).Once()
 
-	err := s.testClient.StreamProcLogs("test-job-id")
+	err := s.testClient.StreamProcLogs(context.Background(), "test-job-id", DefaultStreamOptions())
 	assert.NoError(t, err)
 	s.mockConfigLoader.AssertExpectations(t)
 }
@@ -495,7 +622,7 @@ func (s *ClientTestSuite) TestLogStreamForBadWebSocketHandshake() {
 
 	s.mockConfigLoader.On("Load").Return(proctorConfig, config.ConfigError{}).Once()
 
-	errStreamLogs := s.testClient.StreamProcLogs("test-job-id")
+	errStreamLogs := s.testClient.StreamProcLogs(context.Background(), "test-job-id", DefaultStreamOptions())
 	assert.Equal(t, errors.New("websocket: bad handshake"), errStreamLogs)
 	s.mockConfigLoader.AssertExpectations(t)
 }
@@ -513,7 +640,7 @@ func (s *ClientTestSuite) TestLogStreamForUnauthorizedUser() {
 
 	s.mockConfigLoader.On("Load").Return(proctorConfig, config.ConfigError{}).Once()
 
-	errStreamLogs := s.testClient.StreamProcLogs("test-job-id")
+	errStreamLogs := s.testClient.StreamProcLogs(context.Background(), "test-job-id", DefaultStreamOptions())
 	assert.Error(t, errors.New(http.StatusText(http.StatusUnauthorized)), errStreamLogs)
 	s.mockConfigLoader.AssertExpectations(t)
 
@@ -0,0 +1,23 @@
+package daemon
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFromContextReturnsClientStashedByNewContext(t *testing.T) {
+	expectedClient := NewClient(nil, nil)
+
+	ctx := NewContext(context.Background(), expectedClient)
+
+	actualClient, ok := FromContext(ctx)
+	assert.True(t, ok)
+	assert.Equal(t, expectedClient, actualClient)
+}
+
+func TestFromContextReturnsFalseWhenNoClientStashed(t *testing.T) {
+	_, ok := FromContext(context.Background())
+	assert.False(t, ok)
+}
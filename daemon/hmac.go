@@ -0,0 +1,80 @@
+package daemon
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gojektech/proctor/config"
+)
+
+const (
+	hmacAuthScheme       = "Proctor-HMAC"
+	hmacDateHeaderKey    = "X-Proctor-Date"
+	hmacSigHeaderKey     = "X-Proctor-Signature"
+	maxResponseClockSkew = 5 * time.Minute
+)
+
+// signRequest signs req with an HMAC-SHA256 over
+// "METHOD \n PATH \n DATE \n SHA256(BODY)" using proctorConfig.SigningKey,
+// and attaches the resulting Authorization and X-Proctor-Date headers. It is
+// used instead of the legacy ACCESS_TOKEN header/bearer token when
+// proctorConfig.SigningKey is set.
+func signRequest(req *http.Request, proctorConfig config.ProctorConfig, body []byte) {
+	date := time.Now().UTC().Format(http.TimeFormat)
+	req.Header.Set(hmacDateHeaderKey, date)
+
+	signature := hmacSign(proctorConfig.SigningKey, []string{req.Method, req.URL.Path, date}, body)
+	req.Header.Set("Authorization", fmt.Sprintf(`%s keyId="%s",signature="%s"`, hmacAuthScheme, proctorConfig.Email, signature))
+}
+
+// verifyResponseSignature checks proctord's X-Proctor-Signature reply header
+// against an HMAC-SHA256 over "STATUS \n DATE \n SHA256(BODY)" (the response
+// status code, its X-Proctor-Date header and its body), so a shared
+// proxy sitting between the CLI and proctord can't tamper with responses
+// (mutual signing). It is a no-op when proctorConfig.SigningKey is empty,
+// since there is then no shared secret to verify against. Callers should
+// only invoke this for successful responses: proctord doesn't sign error
+// bodies, so verifying a 4xx/5xx response would always fail and mask the
+// real status-based error behind a spurious HMAC failure.
+func verifyResponseSignature(resp *http.Response, proctorConfig config.ProctorConfig, body []byte) error {
+	if proctorConfig.SigningKey == "" {
+		return nil
+	}
+
+	date := resp.Header.Get(hmacDateHeaderKey)
+	respDate, err := time.Parse(http.TimeFormat, date)
+	if err != nil {
+		return errors.New("HMAC Verification Failed!!!\nMissing or malformed X-Proctor-Date response header.")
+	}
+
+	if skew := time.Since(respDate); skew > maxResponseClockSkew || skew < -maxResponseClockSkew {
+		return fmt.Errorf("HMAC Verification Failed!!!\nResponse date %s is outside the %s clock-skew allowance.", date, maxResponseClockSkew)
+	}
+
+	expected := hmacSign(proctorConfig.SigningKey, []string{strconv.Itoa(resp.StatusCode), date}, body)
+	actual := resp.Header.Get(hmacSigHeaderKey)
+	if !hmac.Equal([]byte(expected), []byte(actual)) {
+		return errors.New("HMAC Verification Failed!!!\nX-Proctor-Signature response header does not match the expected signature. The response may have been tampered with in transit.")
+	}
+	return nil
+}
+
+// hmacSign computes the base64-encoded HMAC-SHA256 over parts joined with
+// "\n", followed by the hex-encoded SHA256 of body, using signingKey as the
+// shared secret.
+func hmacSign(signingKey string, parts []string, body []byte) string {
+	bodyHash := sha256.Sum256(body)
+	payload := strings.Join(parts, "\n") + "\n" + hex.EncodeToString(bodyHash[:])
+
+	mac := hmac.New(sha256.New, []byte(signingKey))
+	mac.Write([]byte(payload))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
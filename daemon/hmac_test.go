@@ -0,0 +1,174 @@
+package daemon
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gojektech/proctor/config"
+	"github.com/gojektech/proctor/proctord/utility"
+	"github.com/stretchr/testify/assert"
+	"github.com/thingful/httpmock"
+)
+
+func TestHMACSignIsDeterministicForTheSamePayload(t *testing.T) {
+	signature := hmacSign("signing-key", []string{"GET", "/jobs/metadata", "Thu, 01 Jan 2026 00:00:00 GMT"}, []byte("body"))
+
+	assert.Equal(t, signature, hmacSign("signing-key", []string{"GET", "/jobs/metadata", "Thu, 01 Jan 2026 00:00:00 GMT"}, []byte("body")))
+	assert.NotEqual(t, signature, hmacSign("other-key", []string{"GET", "/jobs/metadata", "Thu, 01 Jan 2026 00:00:00 GMT"}, []byte("body")))
+	assert.NotEqual(t, signature, hmacSign("signing-key", []string{"POST", "/jobs/metadata", "Thu, 01 Jan 2026 00:00:00 GMT"}, []byte("body")))
+	assert.NotEqual(t, signature, hmacSign("signing-key", []string{"GET", "/jobs/metadata", "Thu, 01 Jan 2026 00:00:00 GMT"}, []byte("other-body")))
+}
+
+func (s *ClientTestSuite) TestListProcsSignsRequestWithHMACWhenSigningKeyIsSet() {
+	t := s.T()
+
+	proctorConfig := config.ProctorConfig{Host: "proctor.example.com", Email: "proctor@example.com", SigningKey: "shared-secret"}
+
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	httpmock.RegisterStubRequest(
+		httpmock.NewStubRequest(
+			"GET",
+			"http://"+proctorConfig.Host+"/jobs/metadata",
+			func(req *http.Request) (*http.Response, error) {
+				assert.Empty(t, req.Header.Get(utility.AccessTokenHeaderKey))
+
+				date := req.Header.Get(hmacDateHeaderKey)
+				assert.NotEmpty(t, date)
+
+				expectedSignature := hmacSign(proctorConfig.SigningKey, []string{"GET", "/jobs/metadata", date}, nil)
+				assert.Equal(t, `Proctor-HMAC keyId="proctor@example.com",signature="`+expectedSignature+`"`, req.Header.Get("Authorization"))
+
+				resp := httpmock.NewStringResponse(200, "[]")
+				respDate := time.Now().UTC().Format(http.TimeFormat)
+				resp.Header.Set(hmacDateHeaderKey, respDate)
+				resp.Header.Set(hmacSigHeaderKey, hmacSign(proctorConfig.SigningKey, []string{"200", respDate}, []byte("[]")))
+				return resp, nil
+			},
+		),
+	)
+
+	s.mockConfigLoader.On("Load").Return(proctorConfig, config.ConfigError{}).Once()
+
+	procList, err := s.testClient.ListProcs(context.Background())
+
+	assert.NoError(t, err)
+	assert.Empty(t, procList)
+}
+
+func (s *ClientTestSuite) TestListProcsRejectsResponseWithInvalidSignature() {
+	t := s.T()
+
+	proctorConfig := config.ProctorConfig{Host: "proctor.example.com", Email: "proctor@example.com", SigningKey: "shared-secret"}
+
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	httpmock.RegisterStubRequest(
+		httpmock.NewStubRequest(
+			"GET",
+			"http://"+proctorConfig.Host+"/jobs/metadata",
+			func(req *http.Request) (*http.Response, error) {
+				resp := httpmock.NewStringResponse(200, "[]")
+				resp.Header.Set(hmacDateHeaderKey, time.Now().UTC().Format(http.TimeFormat))
+				resp.Header.Set(hmacSigHeaderKey, "not-the-right-signature")
+				return resp, nil
+			},
+		),
+	)
+
+	s.mockConfigLoader.On("Load").Return(proctorConfig, config.ConfigError{}).Once()
+
+	_, err := s.testClient.ListProcs(context.Background())
+
+	assert.EqualError(t, err, "HMAC Verification Failed!!!\nX-Proctor-Signature response header does not match the expected signature. The response may have been tampered with in transit.")
+}
+
+func (s *ClientTestSuite) TestListProcsRejectsResponseOutsideClockSkewAllowance() {
+	t := s.T()
+
+	proctorConfig := config.ProctorConfig{Host: "proctor.example.com", Email: "proctor@example.com", SigningKey: "shared-secret"}
+
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	httpmock.RegisterStubRequest(
+		httpmock.NewStubRequest(
+			"GET",
+			"http://"+proctorConfig.Host+"/jobs/metadata",
+			func(req *http.Request) (*http.Response, error) {
+				staleDate := time.Now().Add(-10 * time.Minute).UTC().Format(http.TimeFormat)
+				resp := httpmock.NewStringResponse(200, "[]")
+				resp.Header.Set(hmacDateHeaderKey, staleDate)
+				resp.Header.Set(hmacSigHeaderKey, hmacSign(proctorConfig.SigningKey, []string{"200", staleDate}, []byte("[]")))
+				return resp, nil
+			},
+		),
+	)
+
+	s.mockConfigLoader.On("Load").Return(proctorConfig, config.ConfigError{}).Once()
+
+	_, err := s.testClient.ListProcs(context.Background())
+
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "clock-skew allowance")
+	}
+}
+
+func (s *ClientTestSuite) TestListProcsSurfacesUnauthorizedErrorWhenSigningKeyIsSetAndResponseIsUnsigned() {
+	t := s.T()
+
+	proctorConfig := config.ProctorConfig{Host: "proctor.example.com", Email: "proctor@example.com", SigningKey: "shared-secret"}
+
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	httpmock.RegisterStubRequest(
+		httpmock.NewStubRequest(
+			"GET",
+			"http://"+proctorConfig.Host+"/jobs/metadata",
+			func(req *http.Request) (*http.Response, error) {
+				// proctord doesn't sign error bodies, so this 401 carries
+				// neither hmacDateHeaderKey nor hmacSigHeaderKey.
+				return httpmock.NewStringResponse(401, "Unauthorized"), nil
+			},
+		),
+	)
+
+	s.mockConfigLoader.On("Load").Return(proctorConfig, config.ConfigError{}).Once()
+
+	_, err := s.testClient.ListProcs(context.Background())
+
+	assert.EqualError(t, err, unauthorizedError(proctorConfig).Error())
+}
+
+func (s *ClientTestSuite) TestListProcsFallsBackToAccessTokenHeaderWhenSigningKeyIsEmpty() {
+	t := s.T()
+
+	proctorConfig := config.ProctorConfig{Host: "proctor.example.com", Email: "proctor@example.com", AccessToken: "access-token"}
+
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	httpmock.RegisterStubRequest(
+		httpmock.NewStubRequest(
+			"GET",
+			"http://"+proctorConfig.Host+"/jobs/metadata",
+			func(req *http.Request) (*http.Response, error) {
+				assert.Equal(t, "access-token", req.Header.Get(utility.AccessTokenHeaderKey))
+				assert.Empty(t, req.Header.Get("Authorization"))
+				return httpmock.NewStringResponse(200, "[]"), nil
+			},
+		),
+	)
+
+	s.mockConfigLoader.On("Load").Return(proctorConfig, config.ConfigError{}).Once()
+
+	procList, err := s.testClient.ListProcs(context.Background())
+
+	assert.NoError(t, err)
+	assert.Empty(t, procList)
+}
@@ -0,0 +1,272 @@
+package daemon
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os/exec"
+	"runtime"
+	"strings"
+
+	"github.com/gojektech/proctor/config"
+)
+
+const callbackPath = "/callback"
+
+// openBrowserFunc launches the system browser at the given URL. It is a
+// package variable so tests can stub it out instead of spawning a real
+// browser.
+var openBrowserFunc = defaultOpenBrowser
+
+type oidcDiscoveryDocument struct {
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+}
+
+type oidcTokenResponse struct {
+	IDToken      string `json:"id_token"`
+	RefreshToken string `json:"refresh_token"`
+	Error        string `json:"error"`
+	ErrorDesc    string `json:"error_description"`
+}
+
+// Login runs an interactive OIDC/OAuth2 authorization-code+PKCE flow: it
+// discovers the provider, opens the browser to the authorization URL,
+// receives the callback on a localhost listener, exchanges the code for
+// tokens and persists them back to the proctor config file.
+func (c *client) Login(ctx context.Context) error {
+	proctorConfig, err := c.loadConfig()
+	if err != nil {
+		return err
+	}
+	if proctorConfig.OIDCIssuer == "" || proctorConfig.ClientID == "" {
+		return errors.New("OIDC Login Not Configured!!!\nPlease set OIDC_ISSUER and CLIENT_ID in proctor config file.")
+	}
+
+	discovery, err := discoverOIDCProvider(ctx, c.httpClient, proctorConfig.OIDCIssuer)
+	if err != nil {
+		return fmt.Errorf("OIDC Discovery Failed!!!\n%s", err.Error())
+	}
+
+	verifier, challenge, err := generatePKCE()
+	if err != nil {
+		return err
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return err
+	}
+	defer listener.Close()
+
+	redirectURL := fmt.Sprintf("http://127.0.0.1:%d%s", listener.Addr().(*net.TCPAddr).Port, callbackPath)
+
+	state, err := generateState()
+	if err != nil {
+		return err
+	}
+
+	codeCh := make(chan string, 1)
+	errCh := make(chan error, 1)
+	server := &http.Server{Handler: callbackHandler(codeCh, errCh, state)}
+	go server.Serve(listener)
+	defer server.Close()
+
+	authURL := buildAuthorizationURL(discovery.AuthorizationEndpoint, proctorConfig.ClientID, redirectURL, challenge, state)
+	if err := openBrowserFunc(authURL); err != nil {
+		c.printer.Println(fmt.Sprintf("Unable to open the browser automatically. Please open the following URL to log in:\n%s", authURL))
+	}
+
+	select {
+	case code := <-codeCh:
+		tokens, err := exchangeCodeForToken(ctx, c.httpClient, discovery.TokenEndpoint, proctorConfig.ClientID, redirectURL, code, verifier)
+		if err != nil {
+			return err
+		}
+		proctorConfig.IDToken = tokens.IDToken
+		proctorConfig.RefreshToken = tokens.RefreshToken
+		return c.configLoader.Store(proctorConfig)
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// refreshIDToken exchanges proctorConfig.RefreshToken for a new id_token and
+// persists the result back to the proctor config file.
+func (c *client) refreshIDToken(ctx context.Context, proctorConfig config.ProctorConfig) (config.ProctorConfig, error) {
+	discovery, err := discoverOIDCProvider(ctx, c.httpClient, proctorConfig.OIDCIssuer)
+	if err != nil {
+		return proctorConfig, fmt.Errorf("OIDC Discovery Failed!!!\n%s", err.Error())
+	}
+
+	tokens, err := refreshToken(ctx, c.httpClient, discovery.TokenEndpoint, proctorConfig.ClientID, proctorConfig.RefreshToken)
+	if err != nil {
+		return proctorConfig, err
+	}
+
+	proctorConfig.IDToken = tokens.IDToken
+	if tokens.RefreshToken != "" {
+		proctorConfig.RefreshToken = tokens.RefreshToken
+	}
+	if storeErr := c.configLoader.Store(proctorConfig); storeErr != nil {
+		return proctorConfig, storeErr
+	}
+	return proctorConfig, nil
+}
+
+func discoverOIDCProvider(ctx context.Context, httpClient *http.Client, issuer string) (oidcDiscoveryDocument, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(issuer, "/")+"/.well-known/openid-configuration", nil)
+	if err != nil {
+		return oidcDiscoveryDocument{}, err
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return oidcDiscoveryDocument{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return oidcDiscoveryDocument{}, fmt.Errorf("discovery endpoint returned status %d", resp.StatusCode)
+	}
+
+	var discovery oidcDiscoveryDocument
+	if err := json.NewDecoder(resp.Body).Decode(&discovery); err != nil {
+		return oidcDiscoveryDocument{}, err
+	}
+	return discovery, nil
+}
+
+func exchangeCodeForToken(ctx context.Context, httpClient *http.Client, tokenEndpoint, clientID, redirectURL, code, verifier string) (oidcTokenResponse, error) {
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"client_id":     {clientID},
+		"redirect_uri":  {redirectURL},
+		"code":          {code},
+		"code_verifier": {verifier},
+	}
+	return postTokenRequest(ctx, httpClient, tokenEndpoint, form)
+}
+
+func refreshToken(ctx context.Context, httpClient *http.Client, tokenEndpoint, clientID, refreshToken string) (oidcTokenResponse, error) {
+	form := url.Values{
+		"grant_type":    {"refresh_token"},
+		"client_id":     {clientID},
+		"refresh_token": {refreshToken},
+	}
+	return postTokenRequest(ctx, httpClient, tokenEndpoint, form)
+}
+
+func postTokenRequest(ctx context.Context, httpClient *http.Client, tokenEndpoint string, form url.Values) (oidcTokenResponse, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, tokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return oidcTokenResponse{}, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return oidcTokenResponse{}, err
+	}
+	defer resp.Body.Close()
+
+	var tokens oidcTokenResponse
+	if err := json.NewDecoder(resp.Body).Decode(&tokens); err != nil {
+		return oidcTokenResponse{}, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return oidcTokenResponse{}, fmt.Errorf("token endpoint error: %s: %s", tokens.Error, tokens.ErrorDesc)
+	}
+	return tokens, nil
+}
+
+func buildAuthorizationURL(authEndpoint, clientID, redirectURL, challenge, state string) string {
+	query := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {clientID},
+		"redirect_uri":          {redirectURL},
+		"scope":                 {"openid profile email offline_access"},
+		"code_challenge":        {challenge},
+		"code_challenge_method": {"S256"},
+		"state":                 {state},
+	}
+	return authEndpoint + "?" + query.Encode()
+}
+
+// callbackHandler serves the OIDC redirect_uri: it checks the `state` query
+// parameter against the one generated for this login attempt (rejecting the
+// request if it's missing or doesn't match, which defeats CSRF attempts
+// against the ephemeral callback listener), then pulls the `code` query
+// parameter off the request and signals the caller via the provided
+// channels.
+func callbackHandler(codeCh chan<- string, errCh chan<- error, state string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if errMsg := r.URL.Query().Get("error"); errMsg != "" {
+			fmt.Fprintln(w, "Login failed, you may close this tab.")
+			errCh <- errors.New("OIDC Login Failed!!!\n" + errMsg)
+			return
+		}
+		if r.URL.Query().Get("state") != state {
+			fmt.Fprintln(w, "Login failed, you may close this tab.")
+			errCh <- errors.New("OIDC Login Failed!!!\nState parameter mismatch; rejecting possible CSRF attempt.")
+			return
+		}
+		code := r.URL.Query().Get("code")
+		if code == "" {
+			fmt.Fprintln(w, "Login failed, you may close this tab.")
+			errCh <- errors.New("OIDC Login Failed!!!\nNo authorization code in callback")
+			return
+		}
+		fmt.Fprintln(w, "Login successful, you may close this tab.")
+		codeCh <- code
+	}
+}
+
+// generateState returns a cryptographically random value to use as the
+// OAuth2 `state` parameter, binding the authorization request to the
+// callback that completes it.
+func generateState() (string, error) {
+	raw := make([]byte, 16)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(raw), nil
+}
+
+// generatePKCE returns a cryptographically random verifier and its S256
+// challenge, per RFC 7636.
+func generatePKCE() (verifier, challenge string, err error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", "", err
+	}
+	verifier = base64.RawURLEncoding.EncodeToString(raw)
+
+	sum := sha256.Sum256([]byte(verifier))
+	challenge = base64.RawURLEncoding.EncodeToString(sum[:])
+	return verifier, challenge, nil
+}
+
+func defaultOpenBrowser(targetURL string) error {
+	var cmd string
+	var args []string
+
+	switch runtime.GOOS {
+	case "darwin":
+		cmd, args = "open", []string{targetURL}
+	case "windows":
+		cmd, args = "rundll32", []string{"url.dll,FileProtocolHandler", targetURL}
+	default:
+		cmd, args = "xdg-open", []string{targetURL}
+	}
+	return exec.Command(cmd, args...).Start()
+}
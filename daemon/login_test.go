@@ -0,0 +1,142 @@
+package daemon
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+
+	"github.com/gojektech/proctor/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/thingful/httpmock"
+)
+
+// callbackClient bypasses http.DefaultTransport (patched by httpmock for the
+// duration of these tests) so the simulated browser can actually reach the
+// client's localhost callback listener.
+var callbackClient = &http.Client{Transport: &http.Transport{}}
+
+func (s *ClientTestSuite) TestLoginExchangesCodeForTokenAndStoresIt() {
+	t := s.T()
+
+	proctorConfig := config.ProctorConfig{
+		Host:       "proctor.example.com",
+		Email:      "proctor@example.com",
+		OIDCIssuer: "https://issuer.example.com",
+		ClientID:   "proctor-cli",
+	}
+
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	httpmock.RegisterStubRequest(
+		httpmock.NewStubRequest(
+			"GET",
+			"https://issuer.example.com/.well-known/openid-configuration",
+			func(req *http.Request) (*http.Response, error) {
+				return httpmock.NewStringResponse(200, `{
+					"authorization_endpoint": "https://issuer.example.com/auth",
+					"token_endpoint": "https://issuer.example.com/token"
+				}`), nil
+			},
+		),
+	)
+	httpmock.RegisterStubRequest(
+		httpmock.NewStubRequest(
+			"POST",
+			"https://issuer.example.com/token",
+			func(req *http.Request) (*http.Response, error) {
+				return httpmock.NewStringResponse(200, `{"id_token": "new-id-token", "refresh_token": "new-refresh-token"}`), nil
+			},
+		),
+	)
+
+	// Stub the browser launch: instead of opening a real browser, simulate
+	// the user completing the login by hitting our own localhost callback.
+	originalOpenBrowser := openBrowserFunc
+	defer func() { openBrowserFunc = originalOpenBrowser }()
+	openBrowserFunc = func(authURL string) error {
+		parsed, err := url.Parse(authURL)
+		if err != nil {
+			return err
+		}
+		redirectURL := parsed.Query().Get("redirect_uri")
+		state := parsed.Query().Get("state")
+		go callbackClient.Get(redirectURL + "?code=auth-code&state=" + state)
+		return nil
+	}
+
+	s.mockConfigLoader.On("Load").Return(proctorConfig, config.ConfigError{}).Once()
+	s.mockConfigLoader.On("Store", config.ProctorConfig{
+		Host:         "proctor.example.com",
+		Email:        "proctor@example.com",
+		OIDCIssuer:   "https://issuer.example.com",
+		ClientID:     "proctor-cli",
+		IDToken:      "new-id-token",
+		RefreshToken: "new-refresh-token",
+	}).Return(nil).Once()
+
+	err := s.testClient.Login(context.Background())
+
+	assert.NoError(t, err)
+	s.mockConfigLoader.AssertExpectations(t)
+}
+
+func (s *ClientTestSuite) TestLoginFailsWhenCallbackStateDoesNotMatch() {
+	t := s.T()
+
+	proctorConfig := config.ProctorConfig{
+		Host:       "proctor.example.com",
+		Email:      "proctor@example.com",
+		OIDCIssuer: "https://issuer.example.com",
+		ClientID:   "proctor-cli",
+	}
+
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	httpmock.RegisterStubRequest(
+		httpmock.NewStubRequest(
+			"GET",
+			"https://issuer.example.com/.well-known/openid-configuration",
+			func(req *http.Request) (*http.Response, error) {
+				return httpmock.NewStringResponse(200, `{
+					"authorization_endpoint": "https://issuer.example.com/auth",
+					"token_endpoint": "https://issuer.example.com/token"
+				}`), nil
+			},
+		),
+	)
+
+	// Simulate an attacker hitting our localhost callback with a forged
+	// state, racing the real browser redirect.
+	originalOpenBrowser := openBrowserFunc
+	defer func() { openBrowserFunc = originalOpenBrowser }()
+	openBrowserFunc = func(authURL string) error {
+		parsed, err := url.Parse(authURL)
+		if err != nil {
+			return err
+		}
+		redirectURL := parsed.Query().Get("redirect_uri")
+		go callbackClient.Get(redirectURL + "?code=auth-code&state=forged-state")
+		return nil
+	}
+
+	s.mockConfigLoader.On("Load").Return(proctorConfig, config.ConfigError{}).Once()
+
+	err := s.testClient.Login(context.Background())
+
+	assert.EqualError(t, err, "OIDC Login Failed!!!\nState parameter mismatch; rejecting possible CSRF attempt.")
+	s.mockConfigLoader.AssertExpectations(t)
+}
+
+func (s *ClientTestSuite) TestLoginFailsWhenOIDCNotConfigured() {
+	t := s.T()
+
+	proctorConfig := config.ProctorConfig{Host: "proctor.example.com", Email: "proctor@example.com"}
+	s.mockConfigLoader.On("Load").Return(proctorConfig, config.ConfigError{}).Once()
+
+	err := s.testClient.Login(context.Background())
+
+	assert.Equal(t, "OIDC Login Not Configured!!!\nPlease set OIDC_ISSUER and CLIENT_ID in proctor config file.", err.Error())
+	s.mockConfigLoader.AssertExpectations(t)
+}
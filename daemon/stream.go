@@ -0,0 +1,301 @@
+package daemon
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gojektech/proctor/cmd/version"
+	"github.com/gojektech/proctor/config"
+	"github.com/gojektech/proctor/proctord/utility"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	resumeHeaderKey = "Resume"
+	sinceQueryParam = "since"
+
+	minReconnectBackoff  = 100 * time.Millisecond
+	maxReconnectBackoff  = 30 * time.Second
+	maxReconnectAttempts = 20
+)
+
+// StreamOptions controls how StreamProcLogs consumes a proc's log stream.
+type StreamOptions struct {
+	// Follow keeps the stream open and transparently reconnects across
+	// transient disconnects, picking up from the last seen offset. When
+	// false, the stream exits as soon as the server signals EOF (or
+	// disconnects), without reconnecting.
+	Follow bool
+
+	// Since, when non-zero, asks the server to replay logs from this far
+	// back in wall-clock time instead of from the beginning of the job.
+	Since time.Duration
+}
+
+// DefaultStreamOptions returns the options for streaming a proc's logs with
+// no flags: follow the stream until the proc finishes.
+//
+// No CLI command sets StreamOptions from --follow or --since flags: the CLI
+// has only a cmd/version package, with no command/flag-parsing layer, so
+// Follow and Since are set only by callers of StreamProcLogs.
+func DefaultStreamOptions() StreamOptions {
+	return StreamOptions{Follow: true}
+}
+
+// logFrame is a single frame of the proctord log-streaming protocol: each
+// message is tagged with the monotonic offset of the log line it carries, so
+// a reconnecting client can tell proctord where to resume from. Data is
+// []byte rather than string so encoding/json base64-encodes it, preserving
+// log output that isn't valid UTF-8 instead of mangling it into the JSON
+// string.
+type logFrame struct {
+	Offset uint64 `json:"offset"`
+	Data   []byte `json:"data"`
+}
+
+func (c *client) StreamProcLogs(ctx context.Context, jobID string, opts StreamOptions) error {
+	proctorConfig, err := c.loadConfig()
+	if err != nil {
+		return err
+	}
+
+	offsets := newOffsetStore(jobID)
+	offset, err := offsets.Load()
+	if err != nil {
+		return err
+	}
+
+	conn, err := c.dialProcLogs(ctx, proctorConfig, jobID, offset, opts)
+	if err != nil {
+		return err
+	}
+
+	if !opts.Follow {
+		return c.drainProcLogs(ctx, conn, offsets)
+	}
+
+	return c.streamProcLogsWithReconnect(ctx, conn, proctorConfig, jobID, offsets, opts)
+}
+
+func (c *client) dialProcLogs(ctx context.Context, proctorConfig config.ProctorConfig, jobID string, offset uint64, opts StreamOptions) (*websocket.Conn, error) {
+	header := http.Header{}
+	header.Set(utility.UserEmailHeaderKey, proctorConfig.Email)
+	header.Set(utility.AccessTokenHeaderKey, proctorConfig.AccessToken)
+	header.Set(utility.ClientVersionHeaderKey, version.ClientVersion)
+	if offset > 0 {
+		header.Set(resumeHeaderKey, strconv.FormatUint(offset, 10))
+	}
+
+	wsURL := fmt.Sprintf("ws://%s%s?id=%s", proctorConfig.Host, procsLogsPath, jobID)
+	if opts.Since > 0 {
+		wsURL = fmt.Sprintf("%s&%s=%s", wsURL, sinceQueryParam, opts.Since.String())
+	}
+
+	dialer := websocket.Dialer{}
+	conn, _, err := dialer.DialContext(ctx, wsURL, header)
+	return conn, err
+}
+
+// drainProcLogs reads conn until it closes or ctx is cancelled, without
+// reconnecting. It is used for StreamOptions.Follow == false.
+func (c *client) drainProcLogs(ctx context.Context, conn *websocket.Conn, offsets *offsetStore) error {
+	defer conn.Close()
+
+	done := make(chan struct{})
+	defer close(done)
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.Close()
+		case <-done:
+		}
+	}()
+
+	for {
+		frame, err := readLogFrame(conn)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			if websocket.IsCloseError(err, websocket.CloseNormalClosure) {
+				return nil
+			}
+			return err
+		}
+		if offsets.Seen(frame.Offset) {
+			continue
+		}
+		c.printer.Println(string(frame.Data))
+		offsets.Store(frame.Offset)
+	}
+}
+
+// streamProcLogsWithReconnect tails conn until the stream ends, and on any
+// disconnect other than a clean CloseNormalClosure, reconnects with
+// exponential backoff (jittered, capped attempts), resuming from the last
+// persisted offset. It blocks the caller for as long as the stream is live,
+// returning nil once the job's logs finish (or ctx is cancelled) and an
+// error if reconnection is exhausted, so callers get a single, reliable
+// signal for how the stream ended instead of some failures only reaching a
+// log line.
+func (c *client) streamProcLogsWithReconnect(ctx context.Context, conn *websocket.Conn, proctorConfig config.ProctorConfig, jobID string, offsets *offsetStore, opts StreamOptions) error {
+	attempt := 0
+	for {
+		readErr := c.readProcLogs(ctx, conn, offsets)
+		if readErr == nil || ctx.Err() != nil {
+			return nil
+		}
+
+		var dialErr error
+		for {
+			attempt++
+			if attempt > maxReconnectAttempts {
+				return fmt.Errorf("Giving up streaming logs for %s after %d reconnect attempts: %s", jobID, maxReconnectAttempts, readErr.Error())
+			}
+
+			select {
+			case <-time.After(reconnectBackoff(attempt)):
+			case <-ctx.Done():
+				return nil
+			}
+
+			var offset uint64
+			offset, dialErr = offsets.Load()
+			if dialErr == nil {
+				conn, dialErr = c.dialProcLogs(ctx, proctorConfig, jobID, offset, opts)
+			}
+			if dialErr == nil {
+				break
+			}
+		}
+	}
+}
+
+// readProcLogs relays frames off conn until it closes or ctx is cancelled.
+// A nil error means conn closed cleanly (the job finished); any other error
+// signals a transient disconnect worth reconnecting from.
+func (c *client) readProcLogs(ctx context.Context, conn *websocket.Conn, offsets *offsetStore) error {
+	defer conn.Close()
+
+	// done is closed when readProcLogs returns, so the ctx-watcher goroutine
+	// below exits promptly even when ctx is never cancelled, instead of
+	// leaking one goroutine per reconnect attempt for the lifetime of ctx.
+	done := make(chan struct{})
+	defer close(done)
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.Close()
+		case <-done:
+		}
+	}()
+
+	for {
+		frame, err := readLogFrame(conn)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			if websocket.IsCloseError(err, websocket.CloseNormalClosure) {
+				return nil
+			}
+			return err
+		}
+		if offsets.Seen(frame.Offset) {
+			continue
+		}
+		c.printer.Println(string(frame.Data))
+		offsets.Store(frame.Offset)
+	}
+}
+
+func readLogFrame(conn *websocket.Conn) (logFrame, error) {
+	_, message, err := conn.ReadMessage()
+	if err != nil {
+		return logFrame{}, err
+	}
+
+	var frame logFrame
+	if err := json.Unmarshal(message, &frame); err != nil {
+		return logFrame{}, err
+	}
+	return frame, nil
+}
+
+// reconnectBackoff returns a jittered exponential backoff for the given
+// attempt number (1-indexed), ranging from minReconnectBackoff to
+// maxReconnectBackoff.
+func reconnectBackoff(attempt int) time.Duration {
+	backoff := minReconnectBackoff * time.Duration(1<<uint(attempt-1))
+	if backoff <= 0 || backoff > maxReconnectBackoff {
+		backoff = maxReconnectBackoff
+	}
+	jitter := time.Duration(rand.Int63n(int64(backoff)))
+	wait := backoff/2 + jitter/2
+	if wait < minReconnectBackoff {
+		wait = minReconnectBackoff
+	}
+	return wait
+}
+
+// offsetStore persists the last-seen log offset for a job to
+// ~/.proctor/streams/<jobID>.offset, so a reconnecting (or re-invoked)
+// client can resume a stream without losing lines. It also tracks that
+// offset in memory so Seen can drop frames proctord re-sends at or before
+// the Resume boundary, since resuming a stream without re-printing lines
+// depends on that and not just on what offset was requested.
+type offsetStore struct {
+	path     string
+	lastSeen uint64
+}
+
+func newOffsetStore(jobID string) *offsetStore {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		home = "."
+	}
+	return &offsetStore{path: filepath.Join(home, ".proctor", "streams", jobID+".offset")}
+}
+
+func (o *offsetStore) Load() (uint64, error) {
+	data, err := ioutil.ReadFile(o.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, nil
+		}
+		return 0, err
+	}
+
+	offset, err := strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64)
+	if err != nil {
+		return 0, nil
+	}
+	o.lastSeen = offset
+	return offset, nil
+}
+
+func (o *offsetStore) Store(offset uint64) error {
+	o.lastSeen = offset
+	if err := os.MkdirAll(filepath.Dir(o.path), 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(o.path, []byte(strconv.FormatUint(offset, 10)), 0644)
+}
+
+// Seen reports whether offset has already been stored, so a reconnecting
+// stream can drop a frame proctord re-sends around the Resume boundary
+// instead of printing it twice.
+func (o *offsetStore) Seen(offset uint64) bool {
+	return offset != 0 && offset <= o.lastSeen
+}
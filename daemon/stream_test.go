@@ -0,0 +1,188 @@
+package daemon
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gojektech/proctor/config"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func (s *ClientTestSuite) TestStreamProcLogsReconnectsAndResumesAtLastOffset() {
+	t := s.T()
+
+	tmpHome := t.TempDir()
+	originalHome := os.Getenv("HOME")
+	os.Setenv("HOME", tmpHome)
+	defer os.Setenv("HOME", originalHome)
+
+	var connectionCount int32
+	resumeHeaderCh := make(chan string, 1)
+	done := make(chan struct{})
+
+	upgrader := websocket.Upgrader{}
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+
+		if atomic.AddInt32(&connectionCount, 1) == 1 {
+			conn.WriteJSON(logFrame{Offset: 1, Data: []byte("line1")})
+			conn.WriteJSON(logFrame{Offset: 2, Data: []byte("line2")})
+			// Simulate a transient disconnect: close the underlying
+			// connection without a clean websocket close handshake.
+			conn.Close()
+			return
+		}
+
+		resumeHeaderCh <- r.Header.Get(resumeHeaderKey)
+		conn.WriteJSON(logFrame{Offset: 3, Data: []byte("line3")})
+		conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+		conn.Close()
+		close(done)
+	}))
+	defer testServer.Close()
+
+	proctorConfig := config.ProctorConfig{Host: makeHostname(testServer.URL), Email: "proctor@example.com", AccessToken: "access-token"}
+	s.mockConfigLoader.On("Load").Return(proctorConfig, config.ConfigError{}).Once()
+	s.mockPrinter.On("Println", mock.Anything).Return()
+
+	// StreamProcLogs blocks for as long as the stream is live, so it has to
+	// run on its own goroutine for this test to observe the reconnect and
+	// assert on the error it eventually returns.
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- s.testClient.StreamProcLogs(context.Background(), "test-job-id", StreamOptions{Follow: true})
+	}()
+
+	select {
+	case resume := <-resumeHeaderCh:
+		assert.Equal(t, "2", resume)
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for the client to reconnect")
+	}
+
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for the reconnected stream to finish")
+	}
+
+	select {
+	case err := <-errCh:
+		assert.NoError(t, err)
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for StreamProcLogs to return")
+	}
+
+	offsets := newOffsetStore("test-job-id")
+	offset, err := offsets.Load()
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(3), offset)
+}
+
+func (s *ClientTestSuite) TestStreamProcLogsDropsDuplicateFramesResentAfterResume() {
+	t := s.T()
+
+	tmpHome := t.TempDir()
+	originalHome := os.Getenv("HOME")
+	os.Setenv("HOME", tmpHome)
+	defer os.Setenv("HOME", originalHome)
+
+	var connectionCount int32
+	upgrader := websocket.Upgrader{}
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+
+		if atomic.AddInt32(&connectionCount, 1) == 1 {
+			conn.WriteJSON(logFrame{Offset: 1, Data: []byte("line1")})
+			conn.WriteJSON(logFrame{Offset: 2, Data: []byte("line2")})
+			conn.Close()
+			return
+		}
+
+		// Simulate proctord treating Resume as inclusive: it re-sends the
+		// last frame the first connection already delivered.
+		conn.WriteJSON(logFrame{Offset: 2, Data: []byte("line2")})
+		conn.WriteJSON(logFrame{Offset: 3, Data: []byte("line3")})
+		conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+		conn.Close()
+	}))
+	defer testServer.Close()
+
+	proctorConfig := config.ProctorConfig{Host: makeHostname(testServer.URL), Email: "proctor@example.com", AccessToken: "access-token"}
+	s.mockConfigLoader.On("Load").Return(proctorConfig, config.ConfigError{}).Once()
+	s.mockPrinter.On("Println", []interface{}{"line1"}).Return().Once()
+	s.mockPrinter.On("Println", []interface{}{"line2"}).Return().Once()
+	s.mockPrinter.On("Println", []interface{}{"line3"}).Return().Once()
+
+	err := s.testClient.StreamProcLogs(context.Background(), "dedup-job-id", StreamOptions{Follow: true})
+
+	assert.NoError(t, err)
+	s.mockPrinter.AssertExpectations(t)
+}
+
+func (s *ClientTestSuite) TestStreamProcLogsWithFollowFalseExitsAtEOFWithoutReconnecting() {
+	t := s.T()
+
+	tmpHome := t.TempDir()
+	originalHome := os.Getenv("HOME")
+	os.Setenv("HOME", tmpHome)
+	defer os.Setenv("HOME", originalHome)
+
+	var connectionCount int32
+	upgrader := websocket.Upgrader{}
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&connectionCount, 1)
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		conn.WriteJSON(logFrame{Offset: 1, Data: []byte("line1")})
+		conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+		conn.Close()
+	}))
+	defer testServer.Close()
+
+	proctorConfig := config.ProctorConfig{Host: makeHostname(testServer.URL), Email: "proctor@example.com", AccessToken: "access-token"}
+	s.mockConfigLoader.On("Load").Return(proctorConfig, config.ConfigError{}).Once()
+	s.mockPrinter.On("Println", mock.Anything).Return()
+
+	err := s.testClient.StreamProcLogs(context.Background(), "follow-false-job-id", StreamOptions{Follow: false})
+
+	assert.NoError(t, err)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&connectionCount))
+}
+
+func TestOffsetStoreRoundTrip(t *testing.T) {
+	tmpHome := t.TempDir()
+	originalHome := os.Getenv("HOME")
+	os.Setenv("HOME", tmpHome)
+	defer os.Setenv("HOME", originalHome)
+
+	store := newOffsetStore("some-job-id")
+
+	offset, err := store.Load()
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(0), offset)
+
+	assert.NoError(t, store.Store(42))
+
+	offset, err = store.Load()
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(42), offset)
+
+	assert.FileExists(t, filepath.Join(tmpHome, ".proctor", "streams", "some-job-id.offset"))
+}
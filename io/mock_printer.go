@@ -0,0 +1,12 @@
+package io
+
+import "github.com/stretchr/testify/mock"
+
+// MockPrinter is a testify mock implementation of Printer.
+type MockPrinter struct {
+	mock.Mock
+}
+
+func (m *MockPrinter) Println(a ...interface{}) {
+	m.Called(a)
+}
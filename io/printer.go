@@ -0,0 +1,8 @@
+package io
+
+// Printer is the narrow interface used by the daemon client to surface
+// output to the user (log lines, status messages) without depending on
+// fmt/os directly, so it can be mocked in tests.
+type Printer interface {
+	Println(a ...interface{})
+}
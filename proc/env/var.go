@@ -0,0 +1,14 @@
+package env
+
+// VarMetadata describes a single environment variable (argument or secret)
+// that a proc accepts.
+type VarMetadata struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// Vars groups the secret and argument env vars declared by a proc.
+type Vars struct {
+	Secrets []VarMetadata `json:"secrets"`
+	Args    []VarMetadata `json:"args"`
+}
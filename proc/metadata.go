@@ -0,0 +1,10 @@
+package proc
+
+import "github.com/gojektech/proctor/proc/env"
+
+// Metadata describes a proc as returned by proctord's /jobs/metadata endpoint.
+type Metadata struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	EnvVars     env.Vars `json:"env_vars"`
+}
@@ -0,0 +1,10 @@
+package utility
+
+// Header keys used on every request exchanged between the proctor client
+// and proctord.
+const (
+	UserEmailHeaderKey     = "Email-Id"
+	AccessTokenHeaderKey   = "Access-Token"
+	ClientVersionHeaderKey = "Client-Version"
+	ProcName               = "Proc-Name"
+)